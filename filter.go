@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// IngestConfig controls which words ingestOne considers and counts as a
+// hit. It replaces the hardcoded minYear/minOccurrences/wordRegex
+// constants so callers can compute frequencies over a restricted
+// dictionary or with stopwords excluded.
+type IngestConfig struct {
+	MinYear        int
+	MinOccurrences uint64
+	WordRegex      *regexp.Regexp // words matching this are dropped (default: non-word characters)
+	Stopwords      wordSet        // words in this set are dropped; nil disables stopword filtering
+	Dictionary     wordSet        // if non-nil, only words in this set are kept
+}
+
+// DefaultIngestConfig returns the settings this tool has always used:
+// 1960 onward, more than 10,000 occurrences, non-word characters dropped,
+// no stopword or dictionary filtering.
+func DefaultIngestConfig() IngestConfig {
+	return IngestConfig{
+		MinYear:        minYear,
+		MinOccurrences: minOccurrences,
+		WordRegex:      wordRegex,
+	}
+}
+
+// keep reports whether ngram passes cfg's filters (Dictionary, Stopwords,
+// and WordRegex), checked against each space-separated word it contains.
+// It does not check MinOccurrences, which can only be evaluated once a
+// word's total count across years is known.
+func (cfg IngestConfig) keep(ngram string) bool {
+	words := strings.Fields(ngram)
+	if len(words) == 0 {
+		return false
+	}
+	for _, word := range words {
+		if cfg.WordRegex != nil && cfg.WordRegex.MatchString(word) {
+			return false
+		}
+		if cfg.Dictionary != nil && !cfg.Dictionary.has(word) {
+			return false
+		}
+		if cfg.Stopwords.has(word) {
+			return false
+		}
+	}
+	return true
+}
+
+// wordSet is a set of lowercase words used for stopword and dictionary
+// filtering. A nil wordSet contains nothing.
+type wordSet map[string]struct{}
+
+func (s wordSet) has(word string) bool {
+	if s == nil {
+		return false
+	}
+	_, ok := s[word]
+	return ok
+}
+
+// LoadWordSet reads a newline-delimited word list from path, one word per
+// line, and returns it as a wordSet. Blank lines and lines starting with
+// "#" are ignored. Words are lowercased to match ingestOne's normalization.
+func LoadWordSet(path string) (wordSet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	set := make(wordSet)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		set[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// DefaultStopwords returns a compiled set of the ~100 most common English
+// function words, suitable for excluding from frequency output aimed at
+// downstream NLP use.
+func DefaultStopwords() wordSet {
+	words := []string{
+		"a", "about", "above", "after", "again", "all", "am", "an", "and", "any",
+		"are", "as", "at", "be", "because", "been", "before", "being", "below",
+		"between", "both", "but", "by", "can", "did", "do", "does", "doing",
+		"down", "during", "each", "few", "for", "from", "further", "had", "has",
+		"have", "having", "he", "her", "here", "hers", "herself", "him",
+		"himself", "his", "how", "i", "if", "in", "into", "is", "it", "its",
+		"itself", "just", "me", "more", "most", "my", "myself", "no", "nor",
+		"not", "now", "of", "off", "on", "once", "only", "or", "other", "our",
+		"ours", "ourselves", "out", "over", "own", "same", "she", "should",
+		"so", "some", "such", "than", "that", "the", "their", "theirs", "them",
+		"themselves", "then", "there", "these", "they", "this", "those",
+		"through", "to", "too", "under", "until", "up", "very", "was", "we",
+		"were", "what", "when", "where", "which", "while", "who", "whom",
+		"why", "will", "with", "you", "your", "yours", "yourself", "yourselves",
+	}
+	set := make(wordSet, len(words))
+	for _, w := range words {
+		set[w] = struct{}{}
+	}
+	return set
+}
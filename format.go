@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CorpusFormat describes the on-disk layout and token encoding of an
+// n-gram corpus, so ingestOne can parse different Google Books Ngram
+// releases and widths without hardcoding a single layout.
+type CorpusFormat interface {
+	// TotalsGlob is the filename glob (relative to the corpus directory)
+	// for the file holding per-year total word counts.
+	TotalsGlob() string
+	// DataGlob is the filename glob for the gzipped n-gram data files.
+	DataGlob() string
+	// FieldsPerRecord is the expected CSV column count per data row, for
+	// csv.Reader.FieldsPerRecord. Formats whose row layout packs a
+	// variable number of year groups into one row return a negative
+	// value, disabling the fixed-column check.
+	FieldsPerRecord() int
+	// ParseRow takes one CSV record (already split on the format's field
+	// delimiter) and returns the lowercased, space-joined n-gram with POS
+	// tags stripped, its occurrence count for every year present in the
+	// row, and whether the row carried an actual word sequence rather
+	// than a POS-only aggregate or otherwise unusable row.
+	ParseRow(record []string) (ngram string, perYear map[int]uint64, ok bool)
+}
+
+// googleV2 is the original Google Books Ngram v2 US English 1gram
+// layout: one word per row, with an optional "_POS" suffix embedded
+// after an underscore.
+type googleV2 struct{}
+
+// GoogleV2 returns the CorpusFormat this tool originally hardcoded:
+// googlebooks-eng-us-all-1gram-*.gz files with 4 CSV fields per row.
+func GoogleV2() CorpusFormat { return googleV2{} }
+
+func (googleV2) TotalsGlob() string   { return totalsGlob }
+func (googleV2) DataGlob() string     { return dataGlob }
+func (googleV2) FieldsPerRecord() int { return 4 }
+func (googleV2) Normalize(raw string) (string, bool) {
+	word := strings.ToLower(strings.Split(raw, "_")[0]) // underscore separates the 1gram from its POS tag
+	if word == "" {
+		return "", false
+	}
+	return word, true
+}
+func (f googleV2) ParseRow(record []string) (string, map[int]uint64, bool) {
+	return parseV2Row(f.Normalize, record)
+}
+
+// googleNgram generalizes googleV2's filename and tag conventions to
+// n-grams of arbitrary width.
+type googleNgram struct{ n int }
+
+// GoogleNgramN returns a CorpusFormat for the v2-family corpus at width
+// n (e.g. n=2 for googlebooks-eng-us-all-2gram-*.gz), using the same
+// embedded-underscore POS-tag convention as GoogleV2.
+func GoogleNgramN(n int) CorpusFormat { return googleNgram{n: width(n)} }
+
+func (f googleNgram) TotalsGlob() string { return totalsGlob }
+func (f googleNgram) DataGlob() string   { return fmt.Sprintf("googlebooks-eng-us-all-%dgram-*.gz", f.n) }
+func (googleNgram) FieldsPerRecord() int { return 4 }
+func (googleNgram) Normalize(raw string) (string, bool) {
+	return normalizeTokens(raw, func(tok string) string {
+		return strings.Split(tok, "_")[0]
+	})
+}
+func (f googleNgram) ParseRow(record []string) (string, map[int]uint64, bool) {
+	return parseV2Row(f.Normalize, record)
+}
+
+// parseV2Row implements ParseRow for the v2-family layout shared by
+// googleV2 and googleNgram: one CSV row per (ngram, year), with the year
+// and match count in fixed columns 1 and 2.
+func parseV2Row(normalize func(string) (string, bool), record []string) (string, map[int]uint64, bool) {
+	if len(record) < 3 {
+		return "", nil, false
+	}
+	word, ok := normalize(record[0])
+	if !ok {
+		return "", nil, false
+	}
+	year, err := strconv.ParseInt(record[1], 10, 16)
+	if err != nil {
+		return "", nil, false
+	}
+	count, err := strconv.ParseInt(record[2], 10, 64)
+	if err != nil {
+		return "", nil, false
+	}
+	return word, map[int]uint64{int(year): uint64(count)}, true
+}
+
+// googleV3 is the 2020 Google Books Ngram v3 layout: POS tags are a
+// closed set of "_NOUN"-style suffixes rather than arbitrary
+// underscore-delimited text, and POS-only aggregate rows (e.g. "_NOUN_")
+// carry no word at all. Unlike v2, a v3 data row packs every year's
+// counts into one line: "ngram\tyear,match_count,volume_count\t...", so
+// the row's field count varies with how many years the n-gram appears in.
+type googleV3 struct{ n int }
+
+// GoogleV3 returns a CorpusFormat for the 2020 v3 dataset at width n.
+func GoogleV3(n int) CorpusFormat { return googleV3{n: width(n)} }
+
+func (f googleV3) TotalsGlob() string { return "totalcounts-v3-*.txt" }
+func (f googleV3) DataGlob() string   { return fmt.Sprintf("%dgram-v3-*.gz", f.n) }
+
+// FieldsPerRecord is negative: v3 rows carry one field per year the
+// n-gram occurs in, so the column count isn't fixed across rows.
+func (googleV3) FieldsPerRecord() int { return -1 }
+
+func (googleV3) Normalize(raw string) (string, bool) {
+	return normalizeTokens(raw, func(tok string) string {
+		if strings.HasPrefix(tok, "_") {
+			return "" // POS-only aggregate token, not a word
+		}
+		if idx := strings.LastIndex(tok, "_"); idx > 0 && v3POSTags[tok[idx+1:]] {
+			return tok[:idx]
+		}
+		return tok
+	})
+}
+
+// ParseRow decodes a v3 data row: record[0] is the n-gram, and each
+// subsequent field is a comma-packed "year,match_count,volume_count"
+// group for one year the n-gram appears in.
+func (f googleV3) ParseRow(record []string) (string, map[int]uint64, bool) {
+	if len(record) < 2 {
+		return "", nil, false
+	}
+	word, ok := f.Normalize(record[0])
+	if !ok {
+		return "", nil, false
+	}
+	perYear := make(map[int]uint64, len(record)-1)
+	for _, group := range record[1:] {
+		parts := strings.Split(group, ",")
+		if len(parts) != 3 {
+			continue // malformed year group
+		}
+		year, err := strconv.ParseInt(parts[0], 10, 16)
+		if err != nil {
+			continue
+		}
+		count, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		perYear[int(year)] += uint64(count)
+	}
+	if len(perYear) == 0 {
+		return "", nil, false
+	}
+	return word, perYear, true
+}
+
+var v3POSTags = map[string]bool{
+	"NOUN": true, "VERB": true, "ADJ": true, "ADV": true, "PRON": true,
+	"DET": true, "ADP": true, "NUM": true, "CONJ": true, "PRT": true, "X": true,
+}
+
+// normalizeTokens splits raw on whitespace (n-grams wider than one word
+// are space-separated), applies stripTag to each token, and rejoins the
+// non-empty, lowercased results. It reports false if no token survived.
+func normalizeTokens(raw string, stripTag func(string) string) (string, bool) {
+	fields := strings.Fields(raw)
+	words := make([]string, 0, len(fields))
+	for _, tok := range fields {
+		word := strings.ToLower(stripTag(tok))
+		if word == "" {
+			continue
+		}
+		words = append(words, word)
+	}
+	if len(words) == 0 {
+		return "", false
+	}
+	return strings.Join(words, " "), true
+}
+
+func width(n int) int {
+	if n <= 0 {
+		return 1
+	}
+	return n
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGoogleV2Normalize(t *testing.T) {
+	cases := []struct {
+		raw      string
+		word     string
+		wantWord bool
+	}{
+		{"Love_VERB", "love", true},
+		{"love", "love", true},
+		{"_NOUN_", "", false},
+	}
+	for _, c := range cases {
+		word, ok := GoogleV2().(googleV2).Normalize(c.raw)
+		if ok != c.wantWord || word != c.word {
+			t.Errorf("Normalize(%q) = (%q, %v), want (%q, %v)", c.raw, word, ok, c.word, c.wantWord)
+		}
+	}
+}
+
+func TestGoogleNgramNNormalize(t *testing.T) {
+	cases := []struct {
+		raw      string
+		word     string
+		wantWord bool
+	}{
+		{"New_NOUN York_NOUN", "new york", true},
+		{"_NOUN_ _NOUN_", "", false},
+	}
+	f := GoogleNgramN(2).(googleNgram)
+	for _, c := range cases {
+		word, ok := f.Normalize(c.raw)
+		if ok != c.wantWord || word != c.word {
+			t.Errorf("Normalize(%q) = (%q, %v), want (%q, %v)", c.raw, word, ok, c.word, c.wantWord)
+		}
+	}
+}
+
+func TestGoogleV3Normalize(t *testing.T) {
+	cases := []struct {
+		raw      string
+		word     string
+		wantWord bool
+	}{
+		{"love_VERB", "love", true},
+		{"New_NOUN York_NOUN", "new york", true},
+		{"_NOUN_", "", false},
+		{"love", "love", true},
+	}
+	f := GoogleV3(1).(googleV3)
+	for _, c := range cases {
+		word, ok := f.Normalize(c.raw)
+		if ok != c.wantWord || word != c.word {
+			t.Errorf("Normalize(%q) = (%q, %v), want (%q, %v)", c.raw, word, ok, c.word, c.wantWord)
+		}
+	}
+}
+
+// TestGoogleV3ParseRowPackedYears exercises the defining feature of the
+// v3 layout: a single row packing every year's counts into comma-packed
+// trailing fields, rather than one row per (ngram, year) as in v2.
+func TestGoogleV3ParseRowPackedYears(t *testing.T) {
+	f := GoogleV3(1).(googleV3)
+	record := []string{"love_VERB", "1990,5,3", "1991,7,4"}
+
+	word, years, ok := f.ParseRow(record)
+	if !ok {
+		t.Fatalf("ParseRow(%v) ok = false, want true", record)
+	}
+	if word != "love" {
+		t.Errorf("ParseRow(%v) word = %q, want %q", record, word, "love")
+	}
+	want := map[int]uint64{1990: 5, 1991: 7}
+	if !reflect.DeepEqual(years, want) {
+		t.Errorf("ParseRow(%v) years = %v, want %v", record, years, want)
+	}
+}
+
+func TestGoogleV3ParseRowPOSOnlyRow(t *testing.T) {
+	f := GoogleV3(1).(googleV3)
+	if _, _, ok := f.ParseRow([]string{"_NOUN_", "1990,5,3"}); ok {
+		t.Fatalf("ParseRow of a POS-only aggregate row: ok = true, want false")
+	}
+}
+
+func TestGoogleV2ParseRow(t *testing.T) {
+	f := GoogleV2().(googleV2)
+	word, years, ok := f.ParseRow([]string{"love_VERB", "1990", "5", "3"})
+	if !ok {
+		t.Fatal("ParseRow ok = false, want true")
+	}
+	if word != "love" {
+		t.Errorf("word = %q, want %q", word, "love")
+	}
+	if want := map[int]uint64{1990: 5}; !reflect.DeepEqual(years, want) {
+		t.Errorf("years = %v, want %v", years, want)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import "container/heap"
+
+// wordHeap is a min-heap of *wordStat ordered by frequency, used to keep
+// only the k highest-frequency words seen so far without retaining every
+// candidate in memory.
+type wordHeap []*wordStat
+
+func (h wordHeap) Len() int            { return len(h) }
+func (h wordHeap) Less(i, j int) bool  { return h[i].frequency < h[j].frequency }
+func (h wordHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *wordHeap) Push(x interface{}) { *h = append(*h, x.(*wordStat)) }
+
+func (h *wordHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// pushBounded offers w to h, keeping h no larger than k. If h is already at
+// capacity, w replaces the current smallest entry only if w is larger; the
+// displaced entry is discarded.
+func pushBounded(h *wordHeap, w *wordStat, k int) {
+	if h.Len() < k {
+		heap.Push(h, w)
+		return
+	}
+	if k == 0 || w.frequency <= (*h)[0].frequency {
+		return
+	}
+	(*h)[0] = w
+	heap.Fix(h, 0)
+}
+
+// drainDesc empties h, returning its contents ordered from highest to
+// lowest frequency.
+func drainDesc(h *wordHeap) []*wordStat {
+	out := make([]*wordStat, h.Len())
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = heap.Pop(h).(*wordStat)
+	}
+	return out
+}
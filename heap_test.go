@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func stat(word string, freq float64) *wordStat {
+	return &wordStat{word: word, frequency: freq}
+}
+
+func TestPushBoundedRetainsOnlyKLargest(t *testing.T) {
+	var h wordHeap
+	k := 3
+	for _, freq := range []float64{5, 1, 9, 3, 7, 2} {
+		pushBounded(&h, stat("w", freq), k)
+	}
+	if h.Len() != k {
+		t.Fatalf("Len() = %d, want %d", h.Len(), k)
+	}
+
+	got := drainDesc(&h)
+	want := []float64{9, 7, 5}
+	for i, w := range got {
+		if w.frequency != want[i] {
+			t.Errorf("got[%d].frequency = %v, want %v", i, w.frequency, want[i])
+		}
+	}
+}
+
+func TestPushBoundedZeroK(t *testing.T) {
+	var h wordHeap
+	pushBounded(&h, stat("w", 1), 0)
+	if h.Len() != 0 {
+		t.Fatalf("Len() = %d, want 0", h.Len())
+	}
+}
+
+func TestDrainDescEmpty(t *testing.T) {
+	var h wordHeap
+	if got := drainDesc(&h); len(got) != 0 {
+		t.Fatalf("drainDesc(empty) = %v, want empty", got)
+	}
+}
+
+func TestDrainDescDescending(t *testing.T) {
+	var h wordHeap
+	for _, freq := range []float64{2, 8, 4, 6} {
+		pushBounded(&h, stat("w", freq), 10)
+	}
+	got := drainDesc(&h)
+	for i := 1; i < len(got); i++ {
+		if got[i-1].frequency < got[i].frequency {
+			t.Fatalf("drainDesc not descending: %v", got)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// Posting is a single year/count pair in a word's inverted-index entry.
+type Posting struct {
+	Year  int
+	Count uint64
+}
+
+// postings returns w's per-year counts sorted by year, suitable for an
+// inverted-index entry. Words ingested without year granularity (topK's
+// local heaps still carry it, so this only happens for stats assembled by
+// hand) return nil.
+func (w *wordStat) postings() []Posting {
+	if len(w.years) == 0 {
+		return nil
+	}
+	out := make([]Posting, 0, len(w.years))
+	for year, count := range w.years {
+		out = append(out, Posting{Year: year, Count: count})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Year < out[j].Year })
+	return out
+}
+
+// IndexWriter emits one inverted-index entry per word: the word, its
+// aggregate frequency, and its per-year posting list.
+type IndexWriter interface {
+	WriteEntry(word string, frequency float64, postings []Posting) error
+	Flush() error
+}
+
+// NewIndexWriter returns an IndexWriter for the named format ("csv",
+// "jsonl", or "gob"), encoding to w.
+func NewIndexWriter(format string, w io.Writer) (IndexWriter, error) {
+	switch format {
+	case "csv", "":
+		return &csvIndexWriter{w: csv.NewWriter(w)}, nil
+	case "jsonl":
+		return &jsonlIndexWriter{enc: json.NewEncoder(w)}, nil
+	case "gob":
+		return &gobIndexWriter{enc: gob.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("unknown index format %q", format)
+	}
+}
+
+// csvIndexWriter writes word,frequency,year:count,year:count,... rows.
+type csvIndexWriter struct {
+	w *csv.Writer
+}
+
+func (iw *csvIndexWriter) WriteEntry(word string, frequency float64, postings []Posting) error {
+	record := make([]string, 0, len(postings)+2)
+	record = append(record, word, strconv.FormatFloat(frequency, 'f', -1, 64))
+	for _, p := range postings {
+		record = append(record, fmt.Sprintf("%d:%d", p.Year, p.Count))
+	}
+	return iw.w.Write(record)
+}
+
+func (iw *csvIndexWriter) Flush() error {
+	iw.w.Flush()
+	return iw.w.Error()
+}
+
+// jsonlIndexWriter writes one JSON object per line.
+type jsonlIndexWriter struct {
+	enc *json.Encoder
+}
+
+type jsonlIndexEntry struct {
+	Word      string    `json:"word"`
+	Frequency float64   `json:"frequency"`
+	Postings  []Posting `json:"postings"`
+}
+
+func (iw *jsonlIndexWriter) WriteEntry(word string, frequency float64, postings []Posting) error {
+	return iw.enc.Encode(jsonlIndexEntry{Word: word, Frequency: frequency, Postings: postings})
+}
+
+func (iw *jsonlIndexWriter) Flush() error { return nil }
+
+// gobIndexWriter writes a gob-encoded stream of entries, one Encode call
+// per word.
+type gobIndexWriter struct {
+	enc *gob.Encoder
+}
+
+type gobIndexEntry struct {
+	Word      string
+	Frequency float64
+	Postings  []Posting
+}
+
+func (iw *gobIndexWriter) WriteEntry(word string, frequency float64, postings []Posting) error {
+	return iw.enc.Encode(gobIndexEntry{Word: word, Frequency: frequency, Postings: postings})
+}
+
+func (iw *gobIndexWriter) Flush() error { return nil }
@@ -2,7 +2,9 @@ package main
 
 import (
 	"compress/gzip"
+	"context"
 	"encoding/csv"
+	"flag"
 	"fmt"
 	"io"
 	"os"
@@ -11,6 +13,8 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+
+	"golang.org/x/sync/errgroup"
 )
 
 const (
@@ -18,11 +22,15 @@ const (
 	minOccurrences = 10000
 	totalsGlob     = "googlebooks-eng-us-all-totalcounts-*.txt"
 	dataGlob       = "googlebooks-eng-us-all-1gram-*.gz"
+	defaultWorkers = 4
 )
 
+var wordRegex = regexp.MustCompile(`\W`)
+
 type wordStat struct {
 	word      string
 	frequency float64
+	years     map[int]uint64 // per-year occurrence counts, for index output
 }
 
 func (w *wordStat) csvOut() []string {
@@ -30,13 +38,65 @@ func (w *wordStat) csvOut() []string {
 }
 
 func main() {
-	if err := run(os.Args[1], os.Args[2]); err != nil {
+	topK := flag.Int("topk", 0, "only keep the N most frequent words (0 means keep every word above minOccurrences)")
+	indexFile := flag.String("index", "", "optional path to write an inverted index (word -> per-year posting list) alongside the frequency CSV")
+	indexFormat := flag.String("index-format", "csv", "format for -index: csv, jsonl, or gob")
+	workers := flag.Int("workers", defaultWorkers, "number of concurrent ingest workers (bounds concurrent gzip readers)")
+	minYearFlag := flag.Int("min-year", minYear, "ignore usages before this year")
+	minOccurrencesFlag := flag.Uint64("min-occurrences", minOccurrences, "ignore words with fewer total occurrences than this")
+	stopwordsFile := flag.String("stopwords", "", "path to a newline-delimited stopword list to exclude; use \"default\" for the built-in English function-word list")
+	dictionaryFile := flag.String("dictionary", "", "path to a newline-delimited dictionary; only words found in it are kept")
+	corpusFormat := flag.String("format", "v2", "corpus format: v2 (original Google Books Ngram), v3 (2020 release), or ngram (v2-style at -n width)")
+	n := flag.Int("n", 1, "n-gram width, used by -format=v3 and -format=ngram")
+	flag.Parse()
+	args := flag.Args()
+	if len(args) != 2 {
+		fmt.Println("usage: word_frequencies [-topk N] [-index FILE] [-index-format csv|jsonl|gob] [-workers N] [-stopwords FILE|default] [-dictionary FILE] [-format v2|v3|ngram] [-n WIDTH] <data dir> <out file>")
+		os.Exit(1)
+	}
+
+	var format CorpusFormat
+	switch *corpusFormat {
+	case "v2", "":
+		format = GoogleV2()
+	case "v3":
+		format = GoogleV3(*n)
+	case "ngram":
+		format = GoogleNgramN(*n)
+	default:
+		fmt.Println("error: ", fmt.Errorf("unknown -format %q; want v2, v3, or ngram", *corpusFormat), "; exiting")
+		os.Exit(1)
+	}
+
+	cfg := DefaultIngestConfig()
+	cfg.MinYear = *minYearFlag
+	cfg.MinOccurrences = *minOccurrencesFlag
+	if *stopwordsFile == "default" {
+		cfg.Stopwords = DefaultStopwords()
+	} else if *stopwordsFile != "" {
+		set, err := LoadWordSet(*stopwordsFile)
+		if err != nil {
+			fmt.Println("error: ", err, "; exiting")
+			os.Exit(1)
+		}
+		cfg.Stopwords = set
+	}
+	if *dictionaryFile != "" {
+		set, err := LoadWordSet(*dictionaryFile)
+		if err != nil {
+			fmt.Println("error: ", err, "; exiting")
+			os.Exit(1)
+		}
+		cfg.Dictionary = set
+	}
+
+	if err := run(context.Background(), cfg, format, args[0], args[1], *topK, *workers, *indexFile, *indexFormat); err != nil {
 		fmt.Println("error: ", err, "; exiting")
 		os.Exit(1)
 	}
 }
 
-func run(dataDir, outFile string) error {
+func run(ctx context.Context, cfg IngestConfig, format CorpusFormat, dataDir, outFile string, topK, workers int, indexFile, indexFormat string) error {
 	wd, err := os.Getwd()
 	if err != nil {
 		return err
@@ -46,7 +106,7 @@ func run(dataDir, outFile string) error {
 		return err
 	}
 
-	totalsFiles, err := filepath.Glob(filepath.Join(wd, dataDir, totalsGlob))
+	totalsFiles, err := filepath.Glob(filepath.Join(wd, dataDir, format.TotalsGlob()))
 	if err != nil {
 		return err
 	}
@@ -58,9 +118,12 @@ func run(dataDir, outFile string) error {
 		return err
 	}
 	defer totalsFile.Close()
-	total, err := parseTotal(totalsFile)
+	total, err := parseTotal(totalsFile, cfg.MinYear)
+	if err != nil {
+		return err
+	}
 
-	fileNames, err := filepath.Glob(filepath.Join(wd, dataDir, dataGlob))
+	fileNames, err := filepath.Glob(filepath.Join(wd, dataDir, format.DataGlob()))
 	if err != nil {
 		return err
 	}
@@ -76,7 +139,7 @@ func run(dataDir, outFile string) error {
 		files = append(files, file)
 	}
 
-	words, err := runIngest(total, files...)
+	words, err := runIngest(ctx, cfg, format, total, topK, workers, files...)
 	if err != nil {
 		return err
 	}
@@ -95,10 +158,28 @@ func run(dataDir, outFile string) error {
 	if err := w.Error(); err != nil {
 		return err
 	}
-	return nil
+
+	if indexFile == "" {
+		return nil
+	}
+	idxOut, err := os.Create(indexFile)
+	if err != nil {
+		return err
+	}
+	defer idxOut.Close()
+	idx, err := NewIndexWriter(indexFormat, idxOut)
+	if err != nil {
+		return err
+	}
+	for _, wordRecord := range words {
+		if err := idx.WriteEntry(wordRecord.word, wordRecord.frequency, wordRecord.postings()); err != nil {
+			return err
+		}
+	}
+	return idx.Flush()
 }
 
-func parseTotal(src io.Reader) (uint64, error) {
+func parseTotal(src io.Reader, minYear int) (uint64, error) {
 	tr := csv.NewReader(src)
 	tr.Comma = '\t'
 	totals, err := tr.Read()
@@ -115,7 +196,7 @@ func parseTotal(src io.Reader) (uint64, error) {
 		if err != nil {
 			return 0, err
 		}
-		if year < minYear { // ignore older usages
+		if int(year) < minYear { // ignore older usages
 			continue
 		}
 
@@ -128,95 +209,171 @@ func parseTotal(src io.Reader) (uint64, error) {
 	return totalWords, nil
 }
 
-func runIngest(totalWords uint64, srcs ...io.Reader) ([]*wordStat, error) {
-	errs := make(chan error)
-	words := make(chan *wordStat)
-	done := make(chan bool)
-	routines := 0
-	wordRegex := regexp.MustCompile(`\W`)
-
-	for _, src := range srcs {
-		routines++
-		go func(source io.Reader, wChan chan *wordStat, dChan chan bool, eChan chan error) {
-			z, err := gzip.NewReader(source)
-			if err != nil {
-				eChan <- err
-				return
-			}
-			defer z.Close()
-
-			r := csv.NewReader(z)
-			r.Comma = '\t'
-			r.FieldsPerRecord = 4
-			r.LazyQuotes = true
-
-			wordMap := make(map[string]uint64)
-			for {
-				record, err := r.Read()
-				if err == io.EOF {
-					break
-				}
-				if err != nil {
-					eChan <- err
-					return
-				}
-
-				year, err := strconv.ParseInt(record[1], 10, 16)
-				if err != nil {
-					eChan <- err
-					return
-				}
-				if year < minYear { // ignore older usages
-					continue
-				}
+// TopK ingests srcs and returns the k most frequent words (or wider
+// n-grams, depending on format), descending by frequency, without ever
+// holding more than k*workers candidates in memory at once.
+func TopK(ctx context.Context, cfg IngestConfig, format CorpusFormat, totalWords uint64, k, workers int, srcs ...io.Reader) ([]*wordStat, error) {
+	if k <= 0 {
+		return nil, fmt.Errorf("k must be positive")
+	}
+	return runIngest(ctx, cfg, format, totalWords, k, workers, srcs...)
+}
 
-				word := strings.ToLower(strings.Split(record[0], "_")[0]) // underscores separate 1gram from special character
+// runIngest ingests srcs using a pool of at most workers goroutines. ctx
+// bounds the whole operation: workers stop sending results and return
+// ctx.Err() once it's done, and the first worker error cancels the rest.
+func runIngest(ctx context.Context, cfg IngestConfig, format CorpusFormat, totalWords uint64, topK, workers int, srcs ...io.Reader) ([]*wordStat, error) {
+	if workers <= 0 || workers > len(srcs) {
+		workers = len(srcs)
+	}
 
-				// not sure how to handle entries with non-word characters
-				// drop them for now
-				if wordRegex.MatchString(word) {
-					continue
-				}
+	g, ctx := errgroup.WithContext(ctx)
+	jobs := make(chan io.Reader, len(srcs))
+	for _, src := range srcs {
+		jobs <- src
+	}
+	close(jobs)
 
-				count, err := strconv.ParseInt(record[2], 10, 64)
-				if err != nil {
-					eChan <- err
-					return
-				}
-				wordMap[word] += uint64(count)
-			}
-			for word, occurrences := range wordMap {
-				if occurrences > minOccurrences { // don't care about unusual words
-					wChan <- &wordStat{
-						word:      word,
-						frequency: float64(occurrences) / float64(totalWords),
-					}
+	words := make(chan *wordStat)
+	for i := 0; i < workers; i++ {
+		g.Go(func() error {
+			for src := range jobs {
+				if err := ingestOne(ctx, cfg, format, src, totalWords, topK, words); err != nil {
+					return err
 				}
 			}
-			dChan <- true
-		}(src, words, done, errs)
+			return nil
+		})
 	}
 
+	go func() {
+		g.Wait()
+		close(words)
+	}()
+
 	var wordStats []*wordStat
-OuterLoop:
-	for {
-		select {
-		case word := <-words:
+	var topHeap wordHeap
+	for word := range words {
+		if topK > 0 {
+			pushBounded(&topHeap, word, topK)
+		} else {
 			wordStats = append(wordStats, word)
-		case err := <-errs:
-			// TODO cancel all goroutines
-			return nil, err
-		case <-done:
-			routines--
-			if routines == 0 {
-				break OuterLoop
-			}
 		}
 	}
 
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	if topK > 0 {
+		return drainDesc(&topHeap), nil
+	}
+
 	sort.Slice(wordStats, func(i, j int) bool {
 		return wordStats[i].frequency > wordStats[j].frequency
 	})
 
 	return wordStats, nil
 }
+
+// ingestOne reads one gzipped n-gram file in format's layout, aggregates
+// occurrences per n-gram per year, and sends a *wordStat for each n-gram
+// above minOccurrences to wChan. It respects ctx cancellation both while
+// reading and while sending, so a canceled ctx unblocks a worker stuck
+// sending into a full channel.
+func ingestOne(ctx context.Context, cfg IngestConfig, format CorpusFormat, source io.Reader, totalWords uint64, topK int, wChan chan<- *wordStat) error {
+	z, err := gzip.NewReader(source)
+	if err != nil {
+		return err
+	}
+	defer z.Close()
+
+	r := csv.NewReader(z)
+	r.Comma = '\t'
+	r.FieldsPerRecord = format.FieldsPerRecord()
+	r.LazyQuotes = true
+
+	wordMap := make(map[string]map[int]uint64) // n-gram -> year -> occurrences
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		word, counts, ok := format.ParseRow(record)
+		if !ok {
+			continue // POS-only aggregate row, or otherwise unusable
+		}
+
+		// words excluded by a stopword/dictionary filter, or containing
+		// non-word characters, are dropped here
+		if !cfg.keep(word) {
+			continue
+		}
+
+		years, ok := wordMap[word]
+		if !ok {
+			years = make(map[int]uint64)
+			wordMap[word] = years
+		}
+		for year, count := range counts {
+			if year < cfg.MinYear { // ignore older usages
+				continue
+			}
+			years[year] += count
+		}
+	}
+
+	statFor := func(word string, years map[int]uint64) *wordStat {
+		var occurrences uint64
+		for _, count := range years {
+			occurrences += count
+		}
+		if occurrences <= cfg.MinOccurrences { // don't care about unusual words
+			return nil
+		}
+		return &wordStat{
+			word:      word,
+			frequency: float64(occurrences) / float64(totalWords),
+			years:     years,
+		}
+	}
+
+	send := func(stat *wordStat) error {
+		select {
+		case wChan <- stat:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if topK > 0 {
+		// Keep only this worker's k largest candidates so the merge in
+		// runIngest never has to hold every word in the file.
+		var local wordHeap
+		for word, years := range wordMap {
+			if stat := statFor(word, years); stat != nil {
+				pushBounded(&local, stat, topK)
+			}
+		}
+		for _, w := range local {
+			if err := send(w); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for word, years := range wordMap {
+		if stat := statFor(word, years); stat != nil {
+			if err := send(stat); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}